@@ -4,12 +4,30 @@ import (
 	"backend/messages-service/internal/config"
 	"backend/messages-service/internal/kafka"
 	"backend/messages-service/internal/messages"
+	"backend/messages-service/internal/messages/schema"
+	"backend/messages-service/internal/metrics"
+	"backend/messages-service/internal/outbox"
 	"backend/messages-service/internal/storage"
 	"backend/messages-service/internal/storage/postgresql"
+	healthhttp "backend/messages-service/internal/transport/http/health"
 	messageshttp "backend/messages-service/internal/transport/http/messages"
+	schemashttp "backend/messages-service/internal/transport/http/schemas"
+	"context"
+	"errors"
 	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const (
+	outboxPollInterval = 2 * time.Second
+	outboxBatchSize    = 50
 )
 
 func main() {
@@ -18,15 +36,16 @@ func main() {
 	log := slog.New(slog.NewJSONHandler(os.Stdout, nil))
 	log.Info("starting app", slog.String("env", cfg.Env))
 
+	// rootCtx отменяется по SIGINT/SIGTERM и передаётся всем фоновым
+	// воркерам (outbox.Relay, kafka-консьюмерам), чтобы они остановились до
+	// того, как мы закроем producer и БД.
+	rootCtx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
 	dbStorage, err := postgresql.New(cfg.PostgreSQL)
 	if err != nil {
 		panic(err)
 	}
-	defer func() {
-		if err := dbStorage.Close(); err != nil {
-			log.Warn("failed to close postgresql connection", slog.Any("error", err))
-		}
-	}()
 
 	repo := storage.NewMessagesRepo(dbStorage.DB)
 
@@ -34,26 +53,119 @@ func main() {
 	if err != nil {
 		panic(err)
 	}
-	defer func() {
-		if err := producer.Close(); err != nil {
-			log.Warn("failed to close kafka producer", slog.Any("error", err))
-		}
-	}()
+
+	schemaSources := make([]schema.Source, 0, len(cfg.Schemas.Sources))
+	for _, src := range cfg.Schemas.Sources {
+		schemaSources = append(schemaSources, schema.Source{
+			Classification: src.Classification,
+			Path:           src.Path,
+		})
+	}
+
+	schemaValidator, err := schema.New(schemaSources)
+	if err != nil {
+		panic(err)
+	}
+
+	retryTiers := make([]messages.RetryTier, 0, len(cfg.Kafka.RetryTopics))
+	for _, rt := range cfg.Kafka.RetryTopics {
+		retryTiers = append(retryTiers, messages.RetryTier{
+			Topic: rt.Name,
+			Delay: time.Duration(rt.DelaySeconds) * time.Second,
+		})
+	}
+
+	metricsRecorder := metrics.NewRecorder()
 
 	svc := messages.NewService(
 		repo,
-		producer,
-		log,
+		schemaValidator,
+		metricsRecorder,
 		cfg.Retries.MaxLLMAttempts,
 		cfg.Kafka.InputTopic,
 		cfg.Kafka.OutputTopic,
 		cfg.Kafka.DeadLetterTopic,
+		retryTiers,
 	)
 
+	outboxStore := storage.NewOutboxStore(dbStorage.DB)
+	relay := outbox.NewRelay(outboxStore, producer, log, outboxPollInterval, outboxBatchSize)
+
+	var workers sync.WaitGroup
+
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		if err := relay.Run(rootCtx); err != nil && !errors.Is(err, context.Canceled) {
+			log.Warn("outbox relay stopped", slog.Any("error", err))
+		}
+	}()
+
+	if cfg.Transport == config.TransportKafka || cfg.Transport == config.TransportBoth {
+		reader := kafka.NewReader(kafka.ReaderConfig{
+			Brokers: cfg.Kafka.Brokers,
+			Topic:   cfg.Kafka.LLMResultsTopic,
+			GroupID: cfg.Kafka.ValidationGroupID,
+		})
+
+		consumer := kafka.NewValidationConsumer(reader, producer, svc, log, kafka.ConsumerConfig{
+			Workers:         cfg.Kafka.ValidationWorkers,
+			MaxRetries:      cfg.Kafka.ValidationMaxRetries,
+			RetryBackoff:    cfg.Kafka.ValidationRetryBackoff,
+			MaxBackoff:      cfg.Kafka.ValidationMaxBackoff,
+			DeadLetterTopic: cfg.Kafka.DeadLetterTopic,
+		})
+
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			if err := consumer.Run(rootCtx); err != nil && !errors.Is(err, context.Canceled) {
+				log.Warn("llm results consumer stopped", slog.Any("error", err))
+			}
+		}()
+	}
+
+	// Один консьюмер на каждый тир отложенного ретрая: ждёт retry-at и
+	// пересылает сообщение обратно в inputTopic (см. messages.Service).
+	// Тиры заполняются из handleInvalidLLMOutput независимо от Transport —
+	// в том числе для результатов, пришедших через HTTP
+	// /validate_processed_message, — поэтому эти консьюмеры запускаются
+	// всегда, а не только при cfg.Transport == kafka/both.
+	for _, rt := range cfg.Kafka.RetryTopics {
+		retryReader := kafka.NewReader(kafka.ReaderConfig{
+			Brokers: cfg.Kafka.Brokers,
+			Topic:   rt.Name,
+			GroupID: "retry-" + rt.Name,
+		})
+
+		retryConsumer := kafka.NewRetryConsumer(retryReader, producer, log, kafka.RetryConsumerConfig{
+			Tier:       rt.Name,
+			Delay:      time.Duration(rt.DelaySeconds) * time.Second,
+			InputTopic: cfg.Kafka.InputTopic,
+		})
+
+		workers.Add(1)
+		go func(tier string) {
+			defer workers.Done()
+			if err := retryConsumer.Run(rootCtx); err != nil && !errors.Is(err, context.Canceled) {
+				log.Warn("retry consumer stopped", slog.String("tier", tier), slog.Any("error", err))
+			}
+		}(rt.Name)
+	}
+
 	handler := messageshttp.New(svc, log)
+	schemasHandler := schemashttp.New(schemaValidator, schemaSources, cfg.Schemas.AuthToken, log)
+	healthHandler := healthhttp.New(dbStorage.DB, producer, cfg.Kafka.ReadyMaxStaleness, log)
 
 	mux := http.NewServeMux()
 	handler.Register(mux)
+	schemasHandler.Register(mux)
+	healthHandler.Register(mux)
+	mux.Handle("/metrics", promhttp.Handler())
+
+	if cfg.Transport == config.TransportHTTP || cfg.Transport == config.TransportBoth {
+		handler.RegisterValidate(mux)
+	}
 
 	server := &http.Server{
 		Addr:         cfg.HTTPServer.Address,
@@ -63,9 +175,42 @@ func main() {
 		IdleTimeout:  cfg.HTTPServer.IdleTimeout,
 	}
 
-	log.Info("listening http", slog.String("address", cfg.HTTPServer.Address))
+	serverErrCh := make(chan error, 1)
+	go func() {
+		log.Info("listening http", slog.String("address", cfg.HTTPServer.Address))
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			serverErrCh <- err
+			return
+		}
+		serverErrCh <- nil
+	}()
+
+	select {
+	case <-rootCtx.Done():
+		log.Info("shutdown signal received, draining in-flight requests")
+	case err := <-serverErrCh:
+		if err != nil {
+			log.Error("http server error", slog.Any("error", err))
+		}
+		stop() // останавливаем фоновые воркеры тоже, раз сервер уже упал
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.HTTPServer.ShutdownTimeout)
+	defer cancel()
 
-	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-		log.Error("http server error", slog.Any("error", err))
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Error("http server shutdown failed", slog.Any("error", err))
 	}
+
+	workers.Wait()
+
+	if err := producer.Close(); err != nil {
+		log.Warn("failed to close kafka producer", slog.Any("error", err))
+	}
+
+	if err := dbStorage.Close(); err != nil {
+		log.Warn("failed to close postgresql connection", slog.Any("error", err))
+	}
+
+	log.Info("shutdown complete")
 }
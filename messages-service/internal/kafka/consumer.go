@@ -0,0 +1,236 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"backend/messages-service/internal/messages"
+)
+
+// Message — то, что консьюмер получает из Kafka для одной записи топика.
+type Message struct {
+	Partition int
+	Offset    int64
+	Key       []byte
+	Value     []byte
+	Timestamp time.Time
+	Headers   map[string]string
+}
+
+// Reader абстрагирует клиент Kafka (consumer group, чтение партиций) так,
+// чтобы ValidationConsumer можно было тестировать без реального брокера.
+type Reader interface {
+	FetchMessage(ctx context.Context) (Message, error)
+	CommitMessages(ctx context.Context, msgs ...Message) error
+}
+
+// Producer — интерфейс Kafka-продюсера. Healthy/LastSuccessAt нужны
+// /readyz (см. internal/transport/http/health), чтобы отличать "продюсер
+// жив, но брокер недоступен" от нормальной работы.
+type Producer interface {
+	Send(ctx context.Context, topic string, key string, value []byte, headers map[string]string) error
+	// Healthy возвращает false, если последняя отправка завершилась ошибкой.
+	Healthy() bool
+	// LastSuccessAt — время последней успешно доставленной записи.
+	LastSuccessAt() time.Time
+}
+
+// Validator реализуется messages.Service.
+type Validator interface {
+	ValidateProcessedMessage(ctx context.Context, dto messages.ValidateMessageDTO) error
+}
+
+// ConsumerConfig настраивает размер пула воркеров и поведение при ошибках
+// ValidationConsumer.
+//
+// MaxRetries/RetryBackoff/MaxBackoff — свой, отдельный от
+// config.Retries.MaxLLMAttempts бюджет: тот ограничивает, сколько раз
+// письмо в целом ходит через LLM, а MaxRetries здесь — сколько раз один
+// воркер синхронно, блокируя свою партицию, повторяет один и тот же вызов
+// ValidateProcessedMessage при транзиентной ошибке, прежде чем сдаться и
+// отправить сообщение в DLQ. backoff удваивается после каждой попытки, но
+// не больше MaxBackoff, иначе воркер может зависнуть на часы при большом
+// MaxRetries.
+type ConsumerConfig struct {
+	Workers         int
+	MaxRetries      int
+	RetryBackoff    time.Duration
+	MaxBackoff      time.Duration
+	DeadLetterTopic string
+}
+
+// ValidationConsumer читает llm_results_topic пулом воркеров и отдаёт каждое
+// сообщение в Validator.ValidateProcessedMessage — замена HTTP-коллбэка
+// /validate_processed_message от llm-service.
+type ValidationConsumer struct {
+	reader   Reader
+	producer Producer
+	validate Validator
+	log      *slog.Logger
+	cfg      ConsumerConfig
+}
+
+func NewValidationConsumer(reader Reader, producer Producer, validate Validator, log *slog.Logger, cfg ConsumerConfig) *ValidationConsumer {
+	return &ValidationConsumer{
+		reader:   reader,
+		producer: producer,
+		validate: validate,
+		log:      log,
+		cfg:      cfg,
+	}
+}
+
+// Run поднимает cfg.Workers воркеров, читающих из reader, и блокируется,
+// пока все они не завершатся (обычно — по отмене ctx).
+func (c *ValidationConsumer) Run(ctx context.Context) error {
+	var wg sync.WaitGroup
+	errCh := make(chan error, c.cfg.Workers)
+
+	for i := 0; i < c.cfg.Workers; i++ {
+		wg.Add(1)
+		go func(workerID int) {
+			defer wg.Done()
+			c.runWorker(ctx, workerID, errCh)
+		}(i)
+	}
+
+	wg.Wait()
+	close(errCh)
+
+	for err := range errCh {
+		if err != nil {
+			return err
+		}
+	}
+	return ctx.Err()
+}
+
+func (c *ValidationConsumer) runWorker(ctx context.Context, workerID int, errCh chan<- error) {
+	for {
+		if ctx.Err() != nil {
+			return
+		}
+
+		msg, err := c.reader.FetchMessage(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			c.log.Error("fetch llm result message failed",
+				slog.Int("worker", workerID),
+				slog.Any("error", err),
+			)
+			errCh <- err
+			return
+		}
+
+		if err := c.handle(ctx, msg); err != nil {
+			// Ни одна попытка, включая DLQ, не удалась — не коммитим офсет,
+			// сообщение будет перечитано после ребаланса/рестарта.
+			c.log.Error("failed to handle llm result message, offset not committed",
+				slog.Int("worker", workerID),
+				slog.Int("partition", msg.Partition),
+				slog.Int64("offset", msg.Offset),
+				slog.Any("error", err),
+			)
+			continue
+		}
+
+		if err := c.reader.CommitMessages(ctx, msg); err != nil {
+			c.log.Error("failed to commit offset",
+				slog.Int("worker", workerID),
+				slog.Int("partition", msg.Partition),
+				slog.Int64("offset", msg.Offset),
+				slog.Any("error", err),
+			)
+		}
+	}
+}
+
+// handle декодирует DTO и отдаёт его в Validator с ретраями и бэкоффом;
+// после исчерпания MaxRetries пересылает исходный payload в DLQ.
+//
+// Если сообщение несёт заголовок messages.RequestIDHeader (его туда кладёт
+// messageshttp.Handler или предыдущий виток ретрая), correlation ID
+// переживает круговой путь через llm-service: handle кладёт его в ctx и в
+// логгер, обогащённый mail_id, — дальше Validator и вся цепочка вызовов
+// внутри него достают этот логгер через messages.LoggerFromContext.
+func (c *ValidationConsumer) handle(ctx context.Context, msg Message) error {
+	var dto messages.ValidateMessageDTO
+	if err := json.Unmarshal(msg.Value, &dto); err != nil {
+		return c.sendToDeadLetter(ctx, msg, "", fmt.Sprintf("decode: %v", err))
+	}
+
+	log := c.log.With(slog.String("mail_id", dto.ID))
+	if requestID, ok := msg.Headers[messages.RequestIDHeader]; ok && requestID != "" {
+		ctx = messages.ContextWithRequestID(ctx, requestID)
+		log = log.With(slog.String("request_id", requestID))
+	}
+	ctx = messages.ContextWithLogger(ctx, log)
+
+	backoff := c.cfg.RetryBackoff
+	var lastErr error
+
+	for attempt := 0; attempt <= c.cfg.MaxRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(backoff):
+			}
+			if backoff *= 2; backoff > c.cfg.MaxBackoff && c.cfg.MaxBackoff > 0 {
+				backoff = c.cfg.MaxBackoff
+			}
+		}
+
+		lastErr = c.validate.ValidateProcessedMessage(ctx, dto)
+		if lastErr == nil {
+			return nil
+		}
+
+		log.Warn("validate processed message failed, will retry",
+			slog.Int("attempt", attempt+1),
+			slog.Any("error", lastErr),
+		)
+	}
+
+	reason := fmt.Sprintf("validate failed after %d attempts: %v", c.cfg.MaxRetries+1, lastErr)
+	return c.sendToDeadLetter(ctx, msg, dto.ID, reason)
+}
+
+func (c *ValidationConsumer) sendToDeadLetter(ctx context.Context, msg Message, id string, reason string) error {
+	if id == "" {
+		id = string(msg.Key)
+	}
+
+	failed := messages.FailedMessage{
+		ID:        id,
+		Reason:    reason,
+		Timestamp: time.Now().UTC(),
+		Payload:   msg.Value,
+	}
+
+	data, err := json.Marshal(failed)
+	if err != nil {
+		return fmt.Errorf("marshal dead letter message: %w", err)
+	}
+
+	var headers map[string]string
+	if requestID, ok := msg.Headers[messages.RequestIDHeader]; ok && requestID != "" {
+		headers = map[string]string{messages.RequestIDHeader: requestID}
+	}
+
+	if err := c.producer.Send(ctx, c.cfg.DeadLetterTopic, id, data, headers); err != nil {
+		return fmt.Errorf("send to dead letter topic: %w", err)
+	}
+
+	messages.LoggerFromContext(ctx).Info("llm result message sent to dead_letter_topic",
+		slog.String("id", id),
+		slog.String("reason", reason),
+	)
+	return nil
+}
@@ -0,0 +1,131 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"backend/messages-service/internal/messages"
+)
+
+// fakeValidator returns errs[call] on each call (clamped to the last
+// element once calls exceed len(errs)) and records the DTOs it saw.
+type fakeValidator struct {
+	mu    sync.Mutex
+	errs  []error
+	calls int
+	seen  []messages.ValidateMessageDTO
+}
+
+func (v *fakeValidator) ValidateProcessedMessage(_ context.Context, dto messages.ValidateMessageDTO) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	idx := v.calls
+	if idx >= len(v.errs) {
+		idx = len(v.errs) - 1
+	}
+	v.calls++
+	v.seen = append(v.seen, dto)
+	return v.errs[idx]
+}
+
+// fakeProducer implements Producer and records every Send call.
+type fakeProducer struct {
+	mu   sync.Mutex
+	sent []sentMessage
+}
+
+func (p *fakeProducer) Send(_ context.Context, topic string, key string, value []byte, _ map[string]string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.sent = append(p.sent, sentMessage{topic: topic, key: key, value: value})
+	return nil
+}
+
+func (p *fakeProducer) Healthy() bool            { return true }
+func (p *fakeProducer) LastSuccessAt() time.Time { return time.Now() }
+
+func (p *fakeProducer) lastSent() (sentMessage, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.sent) == 0 {
+		return sentMessage{}, false
+	}
+	return p.sent[len(p.sent)-1], true
+}
+
+func newConsumer(validate *fakeValidator, producer *fakeProducer, cfg ConsumerConfig) *ValidationConsumer {
+	return NewValidationConsumer(nil, producer, validate, discardLogger(), cfg)
+}
+
+func TestHandle_DecodeErrorGoesStraightToDeadLetter(t *testing.T) {
+	validate := &fakeValidator{}
+	producer := &fakeProducer{}
+	c := newConsumer(validate, producer, ConsumerConfig{MaxRetries: 3, RetryBackoff: time.Millisecond, DeadLetterTopic: "dead_letter"})
+
+	msg := Message{Key: []byte("mail-1"), Value: []byte(`{"id": 123}`)} // id must be a string
+	if err := c.handle(context.Background(), msg); err != nil {
+		t.Fatalf("handle: %v", err)
+	}
+
+	if validate.calls != 0 {
+		t.Errorf("ValidateProcessedMessage called %d times, want 0 for undecodable payload", validate.calls)
+	}
+
+	sent, ok := producer.lastSent()
+	if !ok || sent.topic != "dead_letter" || sent.key != "mail-1" {
+		t.Fatalf("dead letter send = %+v, ok=%v, want topic=dead_letter key=mail-1", sent, ok)
+	}
+}
+
+func TestHandle_SucceedsAfterRetries(t *testing.T) {
+	validate := &fakeValidator{errs: []error{errors.New("transient"), errors.New("transient"), nil}}
+	producer := &fakeProducer{}
+	c := newConsumer(validate, producer, ConsumerConfig{MaxRetries: 3, RetryBackoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond, DeadLetterTopic: "dead_letter"})
+
+	dto := messages.ValidateMessageDTO{ID: "mail-2"}
+	value, err := json.Marshal(dto)
+	if err != nil {
+		t.Fatalf("marshal dto: %v", err)
+	}
+
+	if err := c.handle(context.Background(), Message{Key: []byte(dto.ID), Value: value}); err != nil {
+		t.Fatalf("handle: %v", err)
+	}
+
+	if validate.calls != 3 {
+		t.Errorf("ValidateProcessedMessage called %d times, want 3", validate.calls)
+	}
+	if _, ok := producer.lastSent(); ok {
+		t.Error("message sent to dead letter topic, want no send on eventual success")
+	}
+}
+
+func TestHandle_ExhaustsRetriesThenDeadLetters(t *testing.T) {
+	validate := &fakeValidator{errs: []error{errors.New("down"), errors.New("down")}}
+	producer := &fakeProducer{}
+	c := newConsumer(validate, producer, ConsumerConfig{MaxRetries: 1, RetryBackoff: time.Millisecond, DeadLetterTopic: "dead_letter"})
+
+	dto := messages.ValidateMessageDTO{ID: "mail-3"}
+	value, err := json.Marshal(dto)
+	if err != nil {
+		t.Fatalf("marshal dto: %v", err)
+	}
+
+	if err := c.handle(context.Background(), Message{Key: []byte(dto.ID), Value: value}); err != nil {
+		t.Fatalf("handle: %v", err)
+	}
+
+	if validate.calls != 2 {
+		t.Errorf("ValidateProcessedMessage called %d times, want 2 (MaxRetries=1 -> initial attempt + 1 retry)", validate.calls)
+	}
+
+	sent, ok := producer.lastSent()
+	if !ok || sent.topic != "dead_letter" || sent.key != "mail-3" {
+		t.Fatalf("dead letter send = %+v, ok=%v, want topic=dead_letter key=mail-3", sent, ok)
+	}
+}
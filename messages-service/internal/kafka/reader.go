@@ -0,0 +1,70 @@
+package kafka
+
+import (
+	"context"
+
+	kafkago "github.com/segmentio/kafka-go"
+)
+
+// ReaderConfig описывает, куда подключаться и в составе какой consumer group
+// читать топик.
+type ReaderConfig struct {
+	Brokers []string
+	Topic   string
+	GroupID string
+}
+
+// kafkaReader — обёртка над kafka-go.Reader, реализующая Reader.
+type kafkaReader struct {
+	r *kafkago.Reader
+}
+
+// NewReader создаёт Reader поверх consumer group kafka-go.
+func NewReader(cfg ReaderConfig) Reader {
+	return &kafkaReader{
+		r: kafkago.NewReader(kafkago.ReaderConfig{
+			Brokers: cfg.Brokers,
+			Topic:   cfg.Topic,
+			GroupID: cfg.GroupID,
+		}),
+	}
+}
+
+func (k *kafkaReader) FetchMessage(ctx context.Context) (Message, error) {
+	msg, err := k.r.FetchMessage(ctx)
+	if err != nil {
+		return Message{}, err
+	}
+
+	var headers map[string]string
+	if len(msg.Headers) > 0 {
+		headers = make(map[string]string, len(msg.Headers))
+		for _, h := range msg.Headers {
+			headers[h.Key] = string(h.Value)
+		}
+	}
+
+	return Message{
+		Partition: msg.Partition,
+		Offset:    msg.Offset,
+		Key:       msg.Key,
+		Value:     msg.Value,
+		Timestamp: msg.Time,
+		Headers:   headers,
+	}, nil
+}
+
+func (k *kafkaReader) CommitMessages(ctx context.Context, msgs ...Message) error {
+	raw := make([]kafkago.Message, 0, len(msgs))
+	for _, m := range msgs {
+		raw = append(raw, kafkago.Message{
+			Partition: m.Partition,
+			Offset:    m.Offset,
+		})
+	}
+	return k.r.CommitMessages(ctx, raw...)
+}
+
+func (k *kafkaReader) Close() error {
+	return k.r.Close()
+}
@@ -0,0 +1,140 @@
+package kafka
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"backend/messages-service/internal/messages"
+)
+
+// RetryHeaderKey — заголовок Kafka-сообщения с моментом времени (RFC3339),
+// после которого RetryConsumer может пересылать сообщение в inputTopic.
+// Проставляется messages.Service при планировании отложенного ретрая.
+const RetryHeaderKey = "retry-at"
+
+// RetryConsumerConfig настраивает один консьюмер одного retry-топика.
+type RetryConsumerConfig struct {
+	Tier       string
+	Delay      time.Duration
+	InputTopic string
+}
+
+// Sender — то немногое, что RetryConsumer нужно от Kafka-продюсера: в
+// отличие от ValidationConsumer, ему не нужны Healthy/LastSuccessAt, так
+// что он не завязан на более широкий Producer и не ломается каждый раз,
+// когда тому добавляют метод под нужды /readyz.
+type Sender interface {
+	Send(ctx context.Context, topic string, key string, value []byte, headers map[string]string) error
+}
+
+// RetryConsumer читает один из retry_NNs топиков и, дождавшись retry-at,
+// пересылает сообщение как есть обратно в inputTopic — замена немедленному
+// реквизиту задачи на hot-loop против сломанной llm-service.
+//
+// Момент retry-at берётся из заголовка RetryHeaderKey (его с джиттером
+// проставляет messages.Service); если заголовка нет, используется
+// msg.Timestamp + cfg.Delay.
+type RetryConsumer struct {
+	reader   Reader
+	producer Sender
+	log      *slog.Logger
+	cfg      RetryConsumerConfig
+}
+
+func NewRetryConsumer(reader Reader, producer Sender, log *slog.Logger, cfg RetryConsumerConfig) *RetryConsumer {
+	return &RetryConsumer{
+		reader:   reader,
+		producer: producer,
+		log:      log,
+		cfg:      cfg,
+	}
+}
+
+// Run читает сообщения по одному, ждёт наступления retry-at и пересылает их
+// в inputTopic, пока ctx не отменён.
+func (c *RetryConsumer) Run(ctx context.Context) error {
+	for {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		msg, err := c.reader.FetchMessage(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			c.log.Error("fetch retry message failed",
+				slog.String("tier", c.cfg.Tier),
+				slog.Any("error", err),
+			)
+			return err
+		}
+
+		if err := c.waitUntilDue(ctx, msg); err != nil {
+			return err
+		}
+
+		log := c.log.With(slog.String("tier", c.cfg.Tier))
+		if requestID, ok := msg.Headers[messages.RequestIDHeader]; ok && requestID != "" {
+			log = log.With(slog.String("request_id", requestID))
+		}
+
+		if err := c.producer.Send(ctx, c.cfg.InputTopic, string(msg.Key), msg.Value, forwardHeaders(msg.Headers)); err != nil {
+			log.Error("failed to forward retry message to input topic", slog.Any("error", err))
+			continue
+		}
+
+		if err := c.reader.CommitMessages(ctx, msg); err != nil {
+			log.Error("failed to commit retry offset", slog.Any("error", err))
+		}
+
+		log.Info("retry message forwarded to input topic", slog.String("input_topic", c.cfg.InputTopic))
+	}
+}
+
+// forwardHeaders возвращает заголовки исходного retry-сообщения без
+// RetryHeaderKey — retry-at нужен только самому RetryConsumer, а
+// request_id (и любые другие заголовки) должен пережить пересылку обратно
+// в inputTopic.
+func forwardHeaders(headers map[string]string) map[string]string {
+	if len(headers) == 0 {
+		return nil
+	}
+
+	out := make(map[string]string, len(headers))
+	for k, v := range headers {
+		if k == RetryHeaderKey {
+			continue
+		}
+		out[k] = v
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
+
+// retryAt вычисляет момент, раньше которого сообщение пересылать нельзя.
+func (c *RetryConsumer) retryAt(msg Message) time.Time {
+	if raw, ok := msg.Headers[RetryHeaderKey]; ok {
+		if parsed, err := time.Parse(time.RFC3339, raw); err == nil {
+			return parsed
+		}
+	}
+	return msg.Timestamp.Add(c.cfg.Delay)
+}
+
+func (c *RetryConsumer) waitUntilDue(ctx context.Context, msg Message) error {
+	wait := time.Until(c.retryAt(msg))
+	if wait <= 0 {
+		return nil
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(wait):
+		return nil
+	}
+}
@@ -0,0 +1,157 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeRetryReader отдаёт заранее заданные сообщения одно за другим, затем
+// блокируется до отмены ctx — так же, как настоящий kafka-go Reader ведёт
+// себя на пустом топике.
+type fakeRetryReader struct {
+	mu       sync.Mutex
+	messages []Message
+	next     int
+	commits  []Message
+}
+
+func (r *fakeRetryReader) FetchMessage(ctx context.Context) (Message, error) {
+	r.mu.Lock()
+	if r.next < len(r.messages) {
+		msg := r.messages[r.next]
+		r.next++
+		r.mu.Unlock()
+		return msg, nil
+	}
+	r.mu.Unlock()
+
+	<-ctx.Done()
+	return Message{}, ctx.Err()
+}
+
+func (r *fakeRetryReader) CommitMessages(ctx context.Context, msgs ...Message) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.commits = append(r.commits, msgs...)
+	return nil
+}
+
+// fakeRetryProducer записывает топик, ключ, значение и момент каждого
+// вызова Send; заголовки не сохраняет.
+type fakeRetryProducer struct {
+	mu   sync.Mutex
+	sent []sentMessage
+}
+
+type sentMessage struct {
+	topic string
+	key   string
+	value []byte
+	at    time.Time
+}
+
+func (p *fakeRetryProducer) Send(ctx context.Context, topic string, key string, value []byte, headers map[string]string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.sent = append(p.sent, sentMessage{topic: topic, key: key, value: value, at: time.Now()})
+	return nil
+}
+
+func (p *fakeRetryProducer) lastSent() (sentMessage, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.sent) == 0 {
+		return sentMessage{}, false
+	}
+	return p.sent[len(p.sent)-1], true
+}
+
+func discardLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+func TestForwardHeaders(t *testing.T) {
+	got := forwardHeaders(map[string]string{
+		RetryHeaderKey: time.Now().Format(time.RFC3339),
+		"request_id":   "req-1",
+	})
+
+	if _, ok := got[RetryHeaderKey]; ok {
+		t.Errorf("forwardHeaders kept %q, want it stripped", RetryHeaderKey)
+	}
+	if got["request_id"] != "req-1" {
+		t.Errorf("forwardHeaders[request_id] = %q, want %q", got["request_id"], "req-1")
+	}
+}
+
+func TestForwardHeadersOnlyRetryAt(t *testing.T) {
+	got := forwardHeaders(map[string]string{RetryHeaderKey: time.Now().Format(time.RFC3339)})
+	if got != nil {
+		t.Errorf("forwardHeaders = %v, want nil once retry-at is stripped", got)
+	}
+}
+
+func TestRetryConsumer_WaitsForRetryAtHeaderThenForwards(t *testing.T) {
+	retryAt := time.Now().Add(80 * time.Millisecond)
+
+	reader := &fakeRetryReader{
+		messages: []Message{
+			{
+				Key:       []byte("mail-1"),
+				Value:     []byte(`{"id":"mail-1"}`),
+				Timestamp: time.Now().Add(-time.Hour), // намеренно "старое", чтобы убедиться, что используется заголовок, а не Timestamp+Delay
+				Headers:   map[string]string{RetryHeaderKey: retryAt.Format(time.RFC3339Nano)},
+			},
+		},
+	}
+	producer := &fakeRetryProducer{}
+
+	consumer := NewRetryConsumer(reader, producer, discardLogger(), RetryConsumerConfig{
+		Tier:       "retry_30s",
+		Delay:      30 * time.Second,
+		InputTopic: "input_topic",
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	errCh := make(chan error, 1)
+	go func() { errCh <- consumer.Run(ctx) }()
+
+	before := time.Now()
+	deadline := time.After(500 * time.Millisecond)
+	for {
+		if _, ok := producer.lastSent(); ok {
+			break
+		}
+		select {
+		case <-deadline:
+			t.Fatal("retry consumer never forwarded the message")
+		case <-time.After(5 * time.Millisecond):
+		}
+	}
+
+	sent, _ := producer.lastSent()
+	if sent.topic != "input_topic" {
+		t.Errorf("forwarded to topic %q, want %q", sent.topic, "input_topic")
+	}
+	if sent.key != "mail-1" {
+		t.Errorf("forwarded with key %q, want %q", sent.key, "mail-1")
+	}
+	if sent.at.Before(retryAt) {
+		t.Errorf("message forwarded at %s, before retry-at %s", sent.at, retryAt)
+	}
+	if sent.at.Sub(before) < 50*time.Millisecond {
+		t.Errorf("message forwarded too early: only %s elapsed since start", sent.at.Sub(before))
+	}
+
+	cancel()
+	if err := <-errCh; err != nil && !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Run returned unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,52 @@
+package messages
+
+import (
+	"context"
+	"log/slog"
+)
+
+// RequestIDHeader — имя Kafka-заголовка, в котором живёт correlation ID, чтобы
+// он переживал круговой путь через llm-service (outbox → Kafka →
+// kafka.ValidationConsumer). На HTTP-стороне значение приходит и уходит под
+// другим именем — X-Request-ID (см. messageshttp.requestIDHeader); оба имени
+// несут один и тот же ID, но сами строки независимы и совпадать не обязаны.
+const RequestIDHeader = "request_id"
+
+type contextKey int
+
+const (
+	requestIDContextKey contextKey = iota
+	loggerContextKey
+)
+
+// ContextWithRequestID кладёт correlation ID в ctx. Источник — заголовок
+// X-Request-ID на входе в messageshttp.Handler или заголовок RequestIDHeader
+// Kafka-сообщения на входе в kafka.ValidationConsumer (разные имена, один и
+// тот же ID).
+func ContextWithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDContextKey, requestID)
+}
+
+// RequestIDFromContext достаёт correlation ID, положенный ContextWithRequestID.
+// Если его нет, возвращает "".
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDContextKey).(string)
+	return id
+}
+
+// ContextWithLogger кладёт в ctx логгер, обычно уже обогащённый полями
+// вроде request_id/mail_id/attempts, чтобы его не пришлось протаскивать
+// отдельным параметром через Service, Repository и Kafka Producer.
+func ContextWithLogger(ctx context.Context, log *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerContextKey, log)
+}
+
+// LoggerFromContext достаёт логгер, положенный ContextWithLogger. Если в
+// ctx логгера нет, возвращает slog.Default() — так вызывающему коду не
+// нужно проверять результат на nil.
+func LoggerFromContext(ctx context.Context) *slog.Logger {
+	if log, ok := ctx.Value(loggerContextKey).(*slog.Logger); ok && log != nil {
+		return log
+	}
+	return slog.Default()
+}
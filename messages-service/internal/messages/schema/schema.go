@@ -0,0 +1,103 @@
+// Package schema компилирует и хранит JSON Schema (Draft 2020-12) документы,
+// по которым валидируется ModelAnswer от LLM, по одному на классификацию
+// плюс отдельная схема DefaultKey на случай, если под классификацию своей
+// схемы нет.
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// DefaultKey — классификация, под которую подставляется схема, если для
+// конкретной классификации отдельного файла не нашлось.
+const DefaultKey = "default"
+
+// Source — один schema-файл на диске и классификация, за которую он отвечает.
+type Source struct {
+	Classification string
+	Path           string
+}
+
+// Validator хранит скомпилированные схемы и потокобезопасно отдаёт их на
+// Validate/Reload.
+type Validator struct {
+	mu      sync.RWMutex
+	schemas map[string]*jsonschema.Schema
+}
+
+// New компилирует схемы из sources один раз при старте.
+func New(sources []Source) (*Validator, error) {
+	v := &Validator{}
+	if err := v.Reload(sources); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// Reload перекомпилирует все схемы заново. Используется и при старте, и из
+// POST /schemas/reload для горячей подгрузки без рестарта.
+func (v *Validator) Reload(sources []Source) error {
+	compiled := make(map[string]*jsonschema.Schema, len(sources))
+
+	for _, src := range sources {
+		c := jsonschema.NewCompiler()
+		c.Draft = jsonschema.Draft2020
+
+		s, err := c.Compile(src.Path)
+		if err != nil {
+			return fmt.Errorf("compile schema %q for classification %q: %w", src.Path, src.Classification, err)
+		}
+		compiled[src.Classification] = s
+	}
+
+	v.mu.Lock()
+	v.schemas = compiled
+	v.mu.Unlock()
+
+	return nil
+}
+
+// Validate проверяет payload по схеме классификации classification, либо по
+// DefaultKey, если отдельной схемы под неё не загружено.
+func (v *Validator) Validate(classification string, payload json.RawMessage) error {
+	v.mu.RLock()
+	s, ok := v.schemas[classification]
+	if !ok {
+		s, ok = v.schemas[DefaultKey]
+	}
+	v.mu.RUnlock()
+
+	if !ok {
+		return fmt.Errorf("no schema registered for classification %q or %q", classification, DefaultKey)
+	}
+
+	var doc any
+	if err := json.Unmarshal(payload, &doc); err != nil {
+		return fmt.Errorf("unmarshal model answer: %w", err)
+	}
+
+	if err := s.Validate(doc); err != nil {
+		return fmt.Errorf("schema validation: %w", err)
+	}
+
+	return nil
+}
+
+// Classifications возвращает отсортированный список загруженных ключей схем
+// — используется GET /schemas.
+func (v *Validator) Classifications() []string {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	keys := make([]string, 0, len(v.schemas))
+	for k := range v.schemas {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,153 @@
+package schema
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeSchema writes a minimal JSON Schema requiring the object field
+// "answer" to be of type typ, and returns its path.
+func writeSchema(t *testing.T, dir, name, typ string) string {
+	t.Helper()
+
+	path := filepath.Join(dir, name)
+	doc := map[string]any{
+		"$schema": "https://json-schema.org/draft/2020-12/schema",
+		"type":    "object",
+		"properties": map[string]any{
+			"answer": map[string]any{"type": typ},
+		},
+		"required": []string{"answer"},
+	}
+
+	data, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatalf("marshal schema doc: %v", err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatalf("write schema file: %v", err)
+	}
+	return path
+}
+
+func TestValidator_ValidateUsesClassificationSchema(t *testing.T) {
+	dir := t.TempDir()
+	sources := []Source{
+		{Classification: "spam", Path: writeSchema(t, dir, "spam.json", "boolean")},
+		{Classification: DefaultKey, Path: writeSchema(t, dir, "default.json", "string")},
+	}
+
+	v, err := New(sources)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	if err := v.Validate("spam", json.RawMessage(`{"answer": true}`)); err != nil {
+		t.Errorf("Validate(spam, bool answer) = %v, want nil", err)
+	}
+	if err := v.Validate("spam", json.RawMessage(`{"answer": "not a bool"}`)); err == nil {
+		t.Error("Validate(spam, string answer) = nil, want schema violation error")
+	}
+}
+
+func TestValidator_ValidateFallsBackToDefault(t *testing.T) {
+	dir := t.TempDir()
+	sources := []Source{
+		{Classification: DefaultKey, Path: writeSchema(t, dir, "default.json", "string")},
+	}
+
+	v, err := New(sources)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	// No schema registered for "unknown_classification" — falls back to default.
+	if err := v.Validate("unknown_classification", json.RawMessage(`{"answer": "ok"}`)); err != nil {
+		t.Errorf("Validate(unknown_classification) = %v, want fallback to default to succeed", err)
+	}
+	if err := v.Validate("unknown_classification", json.RawMessage(`{"answer": 1}`)); err == nil {
+		t.Error("Validate(unknown_classification, wrong type) = nil, want schema violation error")
+	}
+}
+
+func TestValidator_ValidateNoSchemaAndNoDefaultErrors(t *testing.T) {
+	dir := t.TempDir()
+	sources := []Source{
+		{Classification: "spam", Path: writeSchema(t, dir, "spam.json", "boolean")},
+	}
+
+	v, err := New(sources)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	if err := v.Validate("unknown_classification", json.RawMessage(`{"answer": true}`)); err == nil {
+		t.Error("Validate(unknown_classification) = nil, want error when neither classification nor default schema exists")
+	}
+}
+
+func TestValidator_ReloadSwapsSchemas(t *testing.T) {
+	dir := t.TempDir()
+	sources := []Source{
+		{Classification: "spam", Path: writeSchema(t, dir, "spam.json", "boolean")},
+		{Classification: "invoice", Path: writeSchema(t, dir, "invoice.json", "string")},
+	}
+
+	v, err := New(sources)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if err := v.Validate("spam", json.RawMessage(`{"answer": true}`)); err != nil {
+		t.Fatalf("Validate before reload: %v", err)
+	}
+	if err := v.Validate("invoice", json.RawMessage(`{"answer": "ok"}`)); err != nil {
+		t.Fatalf("Validate(invoice) before reload: %v", err)
+	}
+
+	// Reload with the "spam" classification now requiring a string, and
+	// drop the rest — Reload should replace the schema set wholesale, not
+	// merge with the old one.
+	reloaded := []Source{
+		{Classification: "spam", Path: writeSchema(t, dir, "spam2.json", "string")},
+	}
+	if err := v.Reload(reloaded); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+
+	if err := v.Validate("spam", json.RawMessage(`{"answer": true}`)); err == nil {
+		t.Error("Validate(spam, bool) after reload = nil, want error since spam schema now requires a string")
+	}
+	if err := v.Validate("spam", json.RawMessage(`{"answer": "ok"}`)); err != nil {
+		t.Errorf("Validate(spam, string) after reload = %v, want nil", err)
+	}
+	if err := v.Validate("invoice", json.RawMessage(`{"answer": "ok"}`)); err == nil {
+		t.Error("Validate(invoice) after reload = nil, want error since invoice schema was dropped")
+	}
+}
+
+func TestValidator_Classifications(t *testing.T) {
+	dir := t.TempDir()
+	sources := []Source{
+		{Classification: "spam", Path: writeSchema(t, dir, "spam.json", "boolean")},
+		{Classification: DefaultKey, Path: writeSchema(t, dir, "default.json", "string")},
+		{Classification: "invoice", Path: writeSchema(t, dir, "invoice.json", "object")},
+	}
+
+	v, err := New(sources)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	got := v.Classifications()
+	want := []string{DefaultKey, "invoice", "spam"}
+	if len(got) != len(want) {
+		t.Fatalf("Classifications() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Classifications()[%d] = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
@@ -6,6 +6,8 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"math/rand"
+	"sort"
 	"time"
 
 	"github.com/google/uuid"
@@ -13,19 +15,62 @@ import (
 
 // Repository описывает, что мы ожидаем от слоя хранения (Postgres и т.п.).
 // Конкретная реализация будет в internal/storage (Repo).
+//
+// Методы с суффиксом WithOutbox пишут строку в mails и связанные с ней
+// события в таблицу outbox одной SQL-транзакцией, чтобы публикация в Kafka
+// не расходилась с состоянием письма в БД (см. internal/outbox.Relay).
 type Repository interface {
-	CreateMail(ctx context.Context, m *Mail) error
 	GetMail(ctx context.Context, id string) (*Mail, error)
-	IncrementAttempts(ctx context.Context, id string) error
-	MarkAsFailed(ctx context.Context, id string, reason string) error
-	SaveLLMResult(ctx context.Context, id string, classification string, modelAnswer json.RawMessage) error
+	CreateMailWithOutbox(ctx context.Context, m *Mail, events []OutboxEvent) error
+	SaveLLMResultWithOutbox(ctx context.Context, id string, classification string, modelAnswer json.RawMessage, events []OutboxEvent) error
+	MarkAsFailedWithOutbox(ctx context.Context, id string, reason string, events []OutboxEvent) error
+	IncrementAttemptsWithOutbox(ctx context.Context, id string, events []OutboxEvent) error
+}
+
+// OutboxEvent — сообщение, которое должно быть доставлено в Kafka атомарно
+// с изменением строки mails. Реальную отправку делает internal/outbox.Relay,
+// читая неопубликованные события из таблицы outbox.
+type OutboxEvent struct {
+	Topic   string
+	Key     string
+	Payload json.RawMessage
+	Headers map[string]string
 }
 
-// Producer — интерфейс для Kafka-продюсера.
-// Реализация будет в internal/kafka.
-type Producer interface {
-	// Send отправляет сообщение в указанный топик.
-	Send(ctx context.Context, topic string, key string, value []byte) error
+// RetryTier — один уровень отложенного ретрая: топик и базовая задержка
+// перед пересылкой задачи обратно в inputTopic. Настраивается в
+// config.Kafka.RetryTopics, сортируется по возрастанию Delay.
+type RetryTier struct {
+	Topic string
+	Delay time.Duration
+}
+
+// retryHeaderKey — заголовок Kafka-сообщения с моментом времени, после
+// которого kafka.RetryConsumer может пересылать сообщение в inputTopic.
+// Значение — RFC3339. Используется и kafka.RetryConsumer.
+const retryHeaderKey = "retry-at"
+
+// retryJitterFraction — амплитуда джиттера вокруг задержки тира (±20%),
+// чтобы повторные попытки по одному и тому же письму не выстраивались в
+// синхронные волны с попытками других писем той же когорты.
+const retryJitterFraction = 0.2
+
+// SchemaValidator проверяет ModelAnswer от LLM по JSON Schema, подобранной
+// под классификацию письма. Реализация — internal/messages/schema.Validator.
+type SchemaValidator interface {
+	Validate(classification string, payload json.RawMessage) error
+}
+
+// Metrics собирает Prometheus-метрики обработки писем.
+// Реализация — internal/metrics.Recorder, отдаётся наружу через /metrics.
+type Metrics interface {
+	// RecordProcessed отмечает успешную обработку письма и латентность
+	// received_at → processed.
+	RecordProcessed(latency time.Duration)
+	// RecordFailed отмечает письмо, ушедшее в dead_letter_topic.
+	RecordFailed()
+	// RecordRetried отмечает отложенный ретрай в тир с топиком tier.
+	RecordRetried(tier string)
 }
 
 // Mail — доменная сущность письма.
@@ -84,42 +129,82 @@ type FailedMessage struct {
 }
 
 // Service инкапсулирует бизнес-логику messages-service.
+//
+// Service больше не публикует в Kafka напрямую: вместо producer.Send она
+// складывает исходящие события в outbox той же транзакцией, что и
+// изменение mails, а доставкой занимается internal/outbox.Relay.
 type Service struct {
 	repo            Repository
-	producer        Producer
-	log             *slog.Logger
+	schemaValidator SchemaValidator
+	metrics         Metrics
 	maxAttempts     int
 	inputTopic      string
 	outputTopic     string
 	deadLetterTopic string
+	retryTiers      []RetryTier
 }
 
 // NewService конструирует Service.
+//
+// Service больше не принимает *slog.Logger: логгер, обогащённый
+// request_id/mail_id/attempts, достаётся из ctx через LoggerFromContext —
+// его кладёт туда messageshttp.Handler (из X-Request-ID) или
+// kafka.ValidationConsumer (из заголовка RequestIDHeader).
+//
 // maxAttempts — лимит попыток обработки LLM.
 // inputTopic, outputTopic, deadLetterTopic — названия Kafka-топиков.
+// retryTiers — уровни отложенного ретрая (config.Kafka.RetryTopics).
+// Если пуст, невалидный ответ LLM переотправляется в inputTopic немедленно,
+// как раньше. NewService сортирует копию по возрастанию Delay сам —
+// selectRetryTier полагается на этот порядок, а config.yaml ничего не
+// гарантирует.
+// metrics может быть nil — тогда метрики просто не собираются.
 func NewService(
 	repo Repository,
-	producer Producer,
-	log *slog.Logger,
+	schemaValidator SchemaValidator,
+	metrics Metrics,
 	maxAttempts int,
 	inputTopic, outputTopic, deadLetterTopic string,
+	retryTiers []RetryTier,
 ) *Service {
+	sortedTiers := append([]RetryTier(nil), retryTiers...)
+	sort.Slice(sortedTiers, func(i, j int) bool { return sortedTiers[i].Delay < sortedTiers[j].Delay })
+
 	return &Service{
 		repo:            repo,
-		producer:        producer,
-		log:             log,
+		schemaValidator: schemaValidator,
+		metrics:         metrics,
 		maxAttempts:     maxAttempts,
 		inputTopic:      inputTopic,
 		outputTopic:     outputTopic,
 		deadLetterTopic: deadLetterTopic,
+		retryTiers:      sortedTiers,
 	}
 }
 
+// eventHeaders собирает заголовки исходящего OutboxEvent: request_id из
+// ctx (если есть) плюс extra поверх него. Возвращает nil, если заголовков
+// не набралось — OutboxEvent.Headers тогда остаётся пустым, как раньше.
+func eventHeaders(ctx context.Context, extra map[string]string) map[string]string {
+	headers := make(map[string]string, len(extra)+1)
+	if id := RequestIDFromContext(ctx); id != "" {
+		headers[RequestIDHeader] = id
+	}
+	for k, v := range extra {
+		headers[k] = v
+	}
+	if len(headers) == 0 {
+		return nil
+	}
+	return headers
+}
+
 // ProcessIncomingMessage — бизнес-логика для эндпоинта /process.
 //
-// 1. Генерируем ID, если не пришёл.
-// 2. Сохраняем письмо в БД (attempts=0, status="new").
-// 3. Отправляем задачу в Kafka (inputTopic) для llm-service.
+//  1. Генерируем ID, если не пришёл.
+//  2. Сохраняем письмо в БД (attempts=0, status="new") и кладём задачу для
+//     llm-service в outbox — одной транзакцией, чтобы commit в БД и
+//     публикация в Kafka не могли разойтись.
 func (s *Service) ProcessIncomingMessage(ctx context.Context, dto IncomingMessageDTO) error {
 	if dto.Input == "" {
 		return errors.New("input is empty")
@@ -138,6 +223,9 @@ func (s *Service) ProcessIncomingMessage(ctx context.Context, dto IncomingMessag
 		receivedAt = time.Now().UTC()
 	}
 
+	log := LoggerFromContext(ctx).With(slog.String("mail_id", id))
+	ctx = ContextWithLogger(ctx, log)
+
 	mail := &Mail{
 		ID:         id,
 		Input:      dto.Input,
@@ -148,14 +236,6 @@ func (s *Service) ProcessIncomingMessage(ctx context.Context, dto IncomingMessag
 		Status:     "new",
 	}
 
-	if err := s.repo.CreateMail(ctx, mail); err != nil {
-		s.log.Error("failed to save mail",
-			slog.Any("error", err),
-			slog.String("id", id),
-		)
-		return fmt.Errorf("save mail: %w", err)
-	}
-
 	task := LLMTaskMessage{
 		ID:         id,
 		Input:      mail.Input,
@@ -166,26 +246,18 @@ func (s *Service) ProcessIncomingMessage(ctx context.Context, dto IncomingMessag
 
 	data, err := json.Marshal(task)
 	if err != nil {
-		s.log.Error("failed to marshal llm task",
-			slog.Any("error", err),
-			slog.String("id", id),
-		)
+		log.Error("failed to marshal llm task", slog.Any("error", err))
 		return fmt.Errorf("marshal llm task: %w", err)
 	}
 
-	if err := s.producer.Send(ctx, s.inputTopic, id, data); err != nil {
-		s.log.Error("failed to send llm task to kafka",
-			slog.Any("error", err),
-			slog.String("id", id),
-			slog.String("topic", s.inputTopic),
-		)
-		return fmt.Errorf("send to kafka: %w", err)
+	events := []OutboxEvent{{Topic: s.inputTopic, Key: id, Payload: data, Headers: eventHeaders(ctx, nil)}}
+
+	if err := s.repo.CreateMailWithOutbox(ctx, mail, events); err != nil {
+		log.Error("failed to save mail", slog.Any("error", err))
+		return fmt.Errorf("save mail: %w", err)
 	}
 
-	s.log.Info("incoming message queued for llm",
-		slog.String("id", id),
-		slog.String("topic", s.inputTopic),
-	)
+	log.Info("incoming message queued for llm", slog.String("topic", s.inputTopic))
 
 	return nil
 }
@@ -200,25 +272,33 @@ func (s *Service) ValidateProcessedMessage(ctx context.Context, dto ValidateMess
 		return errors.New("id is empty")
 	}
 
+	log := LoggerFromContext(ctx).With(slog.String("mail_id", dto.ID))
+	ctx = ContextWithLogger(ctx, log)
+
+	// Письмо нужно в обеих ветках: ниже — под received_at для метрики
+	// латентности, в handleInvalidLLMOutput — под attempts и исходные поля
+	// для переотправки задачи.
+	mail, err := s.repo.GetMail(ctx, dto.ID)
+	if err != nil {
+		log.Error("failed to get mail for validate_processed_message", slog.Any("error", err))
+		return fmt.Errorf("get mail: %w", err)
+	}
+
+	// attempts уже в mail.Attempts — handleInvalidLLMOutput кладёт в ctx
+	// свой логгер с attempts+1 (номер попытки, которую он сейчас делает),
+	// поэтому в ctx логгер с attempts кладётся только после этой ветки, —
+	// иначе поле задвоилось бы в логах неудачной ветки.
+	log = log.With(slog.Int("attempts", mail.Attempts))
+
 	// Базовая валидация полей, которые мы ожидаем от LLM
 	if err := s.validateLLMOutput(dto); err != nil {
-		s.log.Warn("llm output validation failed",
-			slog.String("id", dto.ID),
-			slog.Any("error", err),
-		)
-		return s.handleInvalidLLMOutput(ctx, dto, err)
+		log.Warn("llm output validation failed", slog.Any("error", err))
+		return s.handleInvalidLLMOutput(ctx, mail, dto, err)
 	}
 
-	// Если всё ок — сохраняем результат в БД
-	if err := s.repo.SaveLLMResult(ctx, dto.ID, dto.Classification, dto.ModelAnswer); err != nil {
-		s.log.Error("failed to save llm result",
-			slog.Any("error", err),
-			slog.String("id", dto.ID),
-		)
-		return fmt.Errorf("save llm result: %w", err)
-	}
+	ctx = ContextWithLogger(ctx, log)
 
-	// Отправляем в output_topic
+	// Готовим событие в output_topic
 	msg := ProcessedMessage{
 		ID:             dto.ID,
 		Classification: dto.Classification,
@@ -227,24 +307,23 @@ func (s *Service) ValidateProcessedMessage(ctx context.Context, dto ValidateMess
 
 	data, err := json.Marshal(msg)
 	if err != nil {
-		s.log.Error("failed to marshal processed message",
-			slog.Any("error", err),
-			slog.String("id", dto.ID),
-		)
+		log.Error("failed to marshal processed message", slog.Any("error", err))
 		return fmt.Errorf("marshal processed message: %w", err)
 	}
 
-	if err := s.producer.Send(ctx, s.outputTopic, dto.ID, data); err != nil {
-		s.log.Error("failed to send processed message to kafka",
-			slog.Any("error", err),
-			slog.String("id", dto.ID),
-			slog.String("topic", s.outputTopic),
-		)
-		return fmt.Errorf("send processed to kafka: %w", err)
+	events := []OutboxEvent{{Topic: s.outputTopic, Key: dto.ID, Payload: data, Headers: eventHeaders(ctx, nil)}}
+
+	// Сохраняем результат в БД и кладём событие в outbox одной транзакцией
+	if err := s.repo.SaveLLMResultWithOutbox(ctx, dto.ID, dto.Classification, dto.ModelAnswer, events); err != nil {
+		log.Error("failed to save llm result", slog.Any("error", err))
+		return fmt.Errorf("save llm result: %w", err)
+	}
+
+	if s.metrics != nil {
+		s.metrics.RecordProcessed(time.Since(mail.ReceivedAt))
 	}
 
-	s.log.Info("llm result accepted",
-		slog.String("id", dto.ID),
+	log.Info("llm result accepted",
 		slog.String("classification", dto.Classification),
 		slog.String("topic", s.outputTopic),
 	)
@@ -252,8 +331,9 @@ func (s *Service) ValidateProcessedMessage(ctx context.Context, dto ValidateMess
 	return nil
 }
 
-// validateLLMOutput — базовая валидация JSON от LLM.
-// Здесь позже можно прикрутить jsonschema/строгую модель.
+// validateLLMOutput — валидация JSON от LLM: базовые проверки на
+// непустоту плюс, если задан SchemaValidator, проверка ModelAnswer по JSON
+// Schema, подобранной под Classification.
 func (s *Service) validateLLMOutput(dto ValidateMessageDTO) error {
 	if dto.Classification == "" {
 		return errors.New("empty classification")
@@ -262,42 +342,50 @@ func (s *Service) validateLLMOutput(dto ValidateMessageDTO) error {
 		return errors.New("empty model_answer")
 	}
 
-	// сюда можно добавить: распарсить ModelAnswer в конкретный struct и проверить поля
+	if s.schemaValidator != nil {
+		if err := s.schemaValidator.Validate(dto.Classification, dto.ModelAnswer); err != nil {
+			return err
+		}
+	}
 
 	return nil
 }
 
-// handleInvalidLLMOutput — логика при невалидном ответе от LLM.
-//
-// 1. Получаем письмо из БД, чтобы знать attempts и исходные данные.
-// 2. Если attempts+1 >= maxAttempts → шлём в DLQ и помечаем как failed.
-// 3. Иначе → attempts++, переотправляем задачу в inputTopic.
-func (s *Service) handleInvalidLLMOutput(ctx context.Context, dto ValidateMessageDTO, validationErr error) error {
-	mail, err := s.repo.GetMail(ctx, dto.ID)
-	if err != nil {
-		s.log.Error("failed to get mail for invalid llm output",
-			slog.Any("error", err),
-			slog.String("id", dto.ID),
-		)
-		return fmt.Errorf("get mail: %w", err)
+// maxFailedReasonLen ограничивает длину Reason в FailedMessage — список
+// ошибок jsonschema может быть длинным, а DLQ-потребители не обязаны
+// хранить его целиком.
+const maxFailedReasonLen = 2000
+
+func truncateReason(reason string) string {
+	if len(reason) <= maxFailedReasonLen {
+		return reason
 	}
+	return reason[:maxFailedReasonLen] + "... (truncated)"
+}
 
+// handleInvalidLLMOutput — логика при невалидном ответе от LLM.
+//
+// mail уже загружен вызывающей стороной (ValidateProcessedMessage), чтобы
+// не ходить в БД дважды.
+//
+// 1. Если attempts+1 >= maxAttempts → шлём в DLQ и помечаем как failed.
+// 2. Иначе → attempts++, переотправляем задачу (немедленно или с задержкой).
+func (s *Service) handleInvalidLLMOutput(ctx context.Context, mail *Mail, dto ValidateMessageDTO, validationErr error) error {
 	currentAttempts := mail.Attempts
 
+	log := LoggerFromContext(ctx).With(slog.Int("attempts", currentAttempts+1))
+	ctx = ContextWithLogger(ctx, log)
+
 	if currentAttempts+1 >= s.maxAttempts {
 		// Достигли лимита — отправляем в DLQ и помечаем как failed.
-		reason := fmt.Sprintf("max attempts reached (%d): %v", s.maxAttempts, validationErr)
+		reason := truncateReason(fmt.Sprintf("max attempts reached (%d): %v", s.maxAttempts, validationErr))
 
-		if err := s.repo.MarkAsFailed(ctx, dto.ID, reason); err != nil {
-			s.log.Error("failed to mark mail as failed",
-				slog.Any("error", err),
-				slog.String("id", dto.ID),
-			)
-			return fmt.Errorf("mark as failed: %w", err)
+		failedPayload, err := json.Marshal(dto)
+		if err != nil {
+			log.Error("failed to marshal failed message payload", slog.Any("error", err))
+			return fmt.Errorf("marshal failed message payload: %w", err)
 		}
 
-		failedPayload, _ := json.Marshal(dto) // best-effort; если упадёт — просто nil
-
 		failedMsg := FailedMessage{
 			ID:        dto.ID,
 			Reason:    reason,
@@ -307,39 +395,29 @@ func (s *Service) handleInvalidLLMOutput(ctx context.Context, dto ValidateMessag
 
 		data, err := json.Marshal(failedMsg)
 		if err != nil {
-			s.log.Error("failed to marshal failed message",
-				slog.Any("error", err),
-				slog.String("id", dto.ID),
-			)
+			log.Error("failed to marshal failed message", slog.Any("error", err))
 			return fmt.Errorf("marshal failed message: %w", err)
 		}
 
-		if err := s.producer.Send(ctx, s.deadLetterTopic, dto.ID, data); err != nil {
-			s.log.Error("failed to send message to dead_letter_topic",
-				slog.Any("error", err),
-				slog.String("id", dto.ID),
-				slog.String("topic", s.deadLetterTopic),
-			)
-			return fmt.Errorf("send to dlq: %w", err)
+		events := []OutboxEvent{{Topic: s.deadLetterTopic, Key: dto.ID, Payload: data, Headers: eventHeaders(ctx, nil)}}
+
+		if err := s.repo.MarkAsFailedWithOutbox(ctx, dto.ID, reason, events); err != nil {
+			log.Error("failed to mark mail as failed", slog.Any("error", err))
+			return fmt.Errorf("mark as failed: %w", err)
 		}
 
-		s.log.Info("message sent to dead_letter_topic",
-			slog.String("id", dto.ID),
-			slog.Int("attempts", currentAttempts+1),
-		)
+		if s.metrics != nil {
+			s.metrics.RecordFailed()
+		}
 
-		return nil
-	}
+		log.Info("message sent to dead_letter_topic")
 
-	// Ещё можем пробовать — инкремент attempts и переотправляем задачу в inputTopic
-	if err := s.repo.IncrementAttempts(ctx, dto.ID); err != nil {
-		s.log.Error("failed to increment attempts",
-			slog.Any("error", err),
-			slog.String("id", dto.ID),
-		)
-		return fmt.Errorf("increment attempts: %w", err)
+		return nil
 	}
 
+	// Ещё можем пробовать — инкремент attempts и переотправляем задачу либо
+	// немедленно в inputTopic (нет настроенных retryTiers), либо с задержкой
+	// через подобранный по числу попыток retry-топик.
 	task := LLMTaskMessage{
 		ID:         mail.ID,
 		Input:      mail.Input,
@@ -350,27 +428,90 @@ func (s *Service) handleInvalidLLMOutput(ctx context.Context, dto ValidateMessag
 
 	data, err := json.Marshal(task)
 	if err != nil {
-		s.log.Error("failed to marshal llm retry task",
-			slog.Any("error", err),
-			slog.String("id", dto.ID),
-		)
+		log.Error("failed to marshal llm retry task", slog.Any("error", err))
 		return fmt.Errorf("marshal llm retry task: %w", err)
 	}
 
-	if err := s.producer.Send(ctx, s.inputTopic, dto.ID, data); err != nil {
-		s.log.Error("failed to send llm retry task to kafka",
-			slog.Any("error", err),
-			slog.String("id", dto.ID),
-			slog.String("topic", s.inputTopic),
+	var event OutboxEvent
+
+	if len(s.retryTiers) == 0 {
+		event = OutboxEvent{Topic: s.inputTopic, Key: dto.ID, Payload: data, Headers: eventHeaders(ctx, nil)}
+	} else {
+		tier := selectRetryTier(s.retryTiers, currentAttempts)
+		delay := applyJitter(tier.Delay)
+		retryAt := time.Now().UTC().Add(delay)
+
+		event = OutboxEvent{
+			Topic:   tier.Topic,
+			Key:     dto.ID,
+			Payload: data,
+			Headers: eventHeaders(ctx, map[string]string{retryHeaderKey: retryAt.Format(time.RFC3339)}),
+		}
+
+		if s.metrics != nil {
+			s.metrics.RecordRetried(tier.Topic)
+		}
+
+		log.Info("llm task scheduled for delayed retry",
+			slog.String("tier_topic", tier.Topic),
+			slog.Duration("tier_delay", tier.Delay),
+			slog.Duration("jittered_delay", delay),
+			slog.Time("retry_at", retryAt),
 		)
-		return fmt.Errorf("send retry to kafka: %w", err)
 	}
 
-	s.log.Info("llm task requeued",
-		slog.String("id", dto.ID),
-		slog.Int("attempts", currentAttempts+1),
-		slog.String("topic", s.inputTopic),
-	)
+	events := []OutboxEvent{event}
+
+	if err := s.repo.IncrementAttemptsWithOutbox(ctx, dto.ID, events); err != nil {
+		log.Error("failed to increment attempts", slog.Any("error", err))
+		return fmt.Errorf("increment attempts: %w", err)
+	}
+
+	log.Info("llm task requeued", slog.String("topic", event.Topic))
 
 	return nil
 }
+
+// selectRetryTier подбирает уровень ретрая под attempts: целевая задержка
+// растёт экспоненциально от задержки первого (самого короткого) тира —
+// base * 2^attempts, — но не превышает задержку последнего (самого
+// длинного) тира. Возвращается первый тир, чья задержка не меньше целевой;
+// tiers должны быть отсортированы по возрастанию Delay.
+func selectRetryTier(tiers []RetryTier, attempts int) RetryTier {
+	base := tiers[0].Delay
+	maxDelay := tiers[len(tiers)-1].Delay
+
+	exp := attempts
+	if exp > 30 {
+		exp = 30 // ограничиваем показатель степени, чтобы не переполнить time.Duration
+	}
+	if exp < 0 {
+		exp = 0
+	}
+
+	target := base * time.Duration(int64(1)<<uint(exp))
+	if target <= 0 || target > maxDelay {
+		target = maxDelay
+	}
+
+	for _, t := range tiers {
+		if t.Delay >= target {
+			return t
+		}
+	}
+	return tiers[len(tiers)-1]
+}
+
+// applyJitter возвращает delay, случайно сдвинутую в пределах
+// ±retryJitterFraction, чтобы повторные попытки не приходили синхронной
+// волной.
+func applyJitter(delay time.Duration) time.Duration {
+	if delay <= 0 {
+		return delay
+	}
+
+	spread := float64(delay) * retryJitterFraction
+	offset := (rand.Float64()*2 - 1) * spread // [-spread; +spread]
+
+	return delay + time.Duration(offset)
+}
@@ -0,0 +1,80 @@
+package messages
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSelectRetryTier(t *testing.T) {
+	tiers := []RetryTier{
+		{Topic: "retry_30s", Delay: 30 * time.Second},
+		{Topic: "retry_2m", Delay: 2 * time.Minute},
+		{Topic: "retry_10m", Delay: 10 * time.Minute},
+		{Topic: "retry_1h", Delay: time.Hour},
+	}
+
+	tests := []struct {
+		name     string
+		attempts int
+		want     string
+	}{
+		{"first attempt stays on shortest tier", 0, "retry_30s"},
+		{"second attempt doubles past 30s into 2m tier", 1, "retry_2m"},
+		{"third attempt (120s target) lands on 2m tier", 2, "retry_2m"},
+		{"fourth attempt (240s target) escalates to 10m tier", 3, "retry_10m"},
+		{"large attempts count caps at the longest tier", 20, "retry_1h"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := selectRetryTier(tiers, tt.attempts)
+			if got.Topic != tt.want {
+				t.Errorf("selectRetryTier(attempts=%d) = %q, want %q", tt.attempts, got.Topic, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewServiceSortsRetryTiersByDelay(t *testing.T) {
+	unsorted := []RetryTier{
+		{Topic: "retry_10m", Delay: 10 * time.Minute},
+		{Topic: "retry_30s", Delay: 30 * time.Second},
+		{Topic: "retry_1h", Delay: time.Hour},
+		{Topic: "retry_2m", Delay: 2 * time.Minute},
+	}
+
+	svc := NewService(nil, nil, nil, 5, "input", "output", "dead_letter", unsorted)
+
+	want := []string{"retry_30s", "retry_2m", "retry_10m", "retry_1h"}
+	if len(svc.retryTiers) != len(want) {
+		t.Fatalf("NewService retryTiers = %v, want %d tiers", svc.retryTiers, len(want))
+	}
+	for i, tier := range svc.retryTiers {
+		if tier.Topic != want[i] {
+			t.Errorf("retryTiers[%d].Topic = %q, want %q", i, tier.Topic, want[i])
+		}
+	}
+
+	if unsorted[0].Topic != "retry_10m" {
+		t.Errorf("NewService mutated caller's slice; unsorted[0] = %q, want unchanged", unsorted[0].Topic)
+	}
+}
+
+func TestApplyJitter(t *testing.T) {
+	delay := 10 * time.Minute
+	lower := delay - delay/5
+	upper := delay + delay/5
+
+	for i := 0; i < 100; i++ {
+		got := applyJitter(delay)
+		if got < lower || got > upper {
+			t.Fatalf("applyJitter(%s) = %s, want within [%s; %s]", delay, got, lower, upper)
+		}
+	}
+}
+
+func TestApplyJitterZeroDelay(t *testing.T) {
+	if got := applyJitter(0); got != 0 {
+		t.Errorf("applyJitter(0) = %s, want 0", got)
+	}
+}
@@ -0,0 +1,58 @@
+// Package metrics собирает Prometheus-метрики messages-service и отдаёт их
+// через promhttp.Handler() на /metrics (см. cmd/main.go).
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Recorder реализует messages.Metrics поверх прометеевских коллекторов.
+type Recorder struct {
+	processed         prometheus.Counter
+	failed            prometheus.Counter
+	retried           *prometheus.CounterVec
+	processingLatency prometheus.Histogram
+}
+
+// NewRecorder регистрирует коллекторы в prometheus.DefaultRegisterer.
+func NewRecorder() *Recorder {
+	return &Recorder{
+		processed: promauto.NewCounter(prometheus.CounterOpts{
+			Name: "messages_processed_total",
+			Help: "Письма, успешно прошедшие валидацию ответа LLM и сохранённые в output_topic.",
+		}),
+		failed: promauto.NewCounter(prometheus.CounterOpts{
+			Name: "messages_failed_total",
+			Help: "Письма, отправленные в dead_letter_topic после исчерпания попыток.",
+		}),
+		retried: promauto.NewCounterVec(prometheus.CounterOpts{
+			Name: "messages_retried_total",
+			Help: "Отложенные ретраи невалидного ответа LLM, по тирам retry-топиков.",
+		}, []string{"tier"}),
+		processingLatency: promauto.NewHistogram(prometheus.HistogramOpts{
+			Name:    "messages_processing_latency_seconds",
+			Help:    "Время от received_at письма до успешного сохранения результата LLM.",
+			Buckets: prometheus.DefBuckets,
+		}),
+	}
+}
+
+// RecordProcessed отмечает успешную обработку письма и латентность
+// received_at → processed.
+func (r *Recorder) RecordProcessed(latency time.Duration) {
+	r.processed.Inc()
+	r.processingLatency.Observe(latency.Seconds())
+}
+
+// RecordFailed отмечает письмо, ушедшее в dead_letter_topic.
+func (r *Recorder) RecordFailed() {
+	r.failed.Inc()
+}
+
+// RecordRetried отмечает отложенный ретрай в тир с топиком tier.
+func (r *Recorder) RecordRetried(tier string) {
+	r.retried.WithLabelValues(tier).Inc()
+}
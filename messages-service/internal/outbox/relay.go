@@ -0,0 +1,84 @@
+// Package outbox реализует доставку событий, накопленных в таблице outbox,
+// в Kafka (классический transactional outbox поверх внешнего брокера).
+package outbox
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"backend/messages-service/internal/messages"
+	"backend/messages-service/internal/storage"
+)
+
+// Producer — то немногое, что Relay нужно от Kafka-продюсера.
+type Producer interface {
+	Send(ctx context.Context, topic string, key string, value []byte, headers map[string]string) error
+}
+
+// Store абстрагирует хранилище outbox-строк (реализовано storage.OutboxStore).
+type Store interface {
+	ClaimAndDeliver(ctx context.Context, limit int, deliver func(ctx context.Context, row storage.OutboxRow) error) error
+}
+
+// Relay периодически забирает неопубликованные строки из outbox и
+// отправляет их через Producer.
+type Relay struct {
+	store     Store
+	producer  Producer
+	log       *slog.Logger
+	interval  time.Duration
+	batchSize int
+}
+
+// NewRelay конструирует Relay.
+// interval — как часто опрашивать outbox, batchSize — сколько строк
+// забирать за один проход (LIMIT в SELECT ... FOR UPDATE SKIP LOCKED).
+func NewRelay(store Store, producer Producer, log *slog.Logger, interval time.Duration, batchSize int) *Relay {
+	return &Relay{
+		store:     store,
+		producer:  producer,
+		log:       log,
+		interval:  interval,
+		batchSize: batchSize,
+	}
+}
+
+// Run крутит цикл опроса outbox, пока ctx не отменён.
+func (r *Relay) Run(ctx context.Context) error {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			if err := r.poll(ctx); err != nil {
+				r.log.Error("outbox relay poll failed", slog.Any("error", err))
+			}
+		}
+	}
+}
+
+func (r *Relay) poll(ctx context.Context) error {
+	return r.store.ClaimAndDeliver(ctx, r.batchSize, func(ctx context.Context, row storage.OutboxRow) error {
+		log := r.log.With(slog.Int64("outbox_id", row.ID), slog.String("topic", row.Topic))
+		if row.Key != "" {
+			log = log.With(slog.String("mail_id", row.Key))
+		}
+		if requestID := row.Headers[messages.RequestIDHeader]; requestID != "" {
+			ctx = messages.ContextWithRequestID(ctx, requestID)
+			log = log.With(slog.String("request_id", requestID))
+		}
+		ctx = messages.ContextWithLogger(ctx, log)
+
+		if err := r.producer.Send(ctx, row.Topic, row.Key, row.Payload, row.Headers); err != nil {
+			log.Warn("outbox event delivery failed", slog.Any("error", err))
+			return err
+		}
+
+		log.Info("outbox event delivered")
+		return nil
+	})
+}
@@ -0,0 +1,91 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+)
+
+// OutboxRow — строка таблицы outbox, ожидающая доставки в Kafka.
+type OutboxRow struct {
+	ID      int64
+	Topic   string
+	Key     string
+	Payload json.RawMessage
+	Headers map[string]string
+}
+
+// OutboxStore читает и помечает строки таблицы outbox для internal/outbox.Relay.
+type OutboxStore struct {
+	db *sql.DB
+}
+
+func NewOutboxStore(db *sql.DB) *OutboxStore {
+	return &OutboxStore{db: db}
+}
+
+// ClaimAndDeliver забирает до limit неопубликованных строк (SELECT ... FOR
+// UPDATE SKIP LOCKED), чтобы несколько реплик Relay не доставляли одно и то
+// же сообщение дважды, и для каждой вызывает deliver. Строка помечается
+// published_at при успехе или получает attempts+1 при ошибке. Всё это
+// делается в одной транзакции, чтобы лок удерживался на всё время доставки
+// забранной пачки.
+func (s *OutboxStore) ClaimAndDeliver(ctx context.Context, limit int, deliver func(ctx context.Context, row OutboxRow) error) error {
+	const selectQuery = `
+		SELECT id, topic, key, payload, headers
+		FROM outbox
+		WHERE published_at IS NULL
+		ORDER BY created_at
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED;
+	`
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, selectQuery, limit)
+	if err != nil {
+		return fmt.Errorf("select outbox rows: %w", err)
+	}
+
+	var claimed []OutboxRow
+	for rows.Next() {
+		var row OutboxRow
+		var rawHeaders []byte
+		if err := rows.Scan(&row.ID, &row.Topic, &row.Key, &row.Payload, &rawHeaders); err != nil {
+			rows.Close()
+			return fmt.Errorf("scan outbox row: %w", err)
+		}
+		if len(rawHeaders) > 0 {
+			if err := json.Unmarshal(rawHeaders, &row.Headers); err != nil {
+				rows.Close()
+				return fmt.Errorf("unmarshal outbox headers: %w", err)
+			}
+		}
+		claimed = append(claimed, row)
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return fmt.Errorf("iterate outbox rows: %w", err)
+	}
+	rows.Close()
+
+	for _, row := range claimed {
+		if err := deliver(ctx, row); err != nil {
+			if _, uerr := tx.ExecContext(ctx, `UPDATE outbox SET attempts = attempts + 1 WHERE id = $1;`, row.ID); uerr != nil {
+				return fmt.Errorf("increment outbox attempts: %w", uerr)
+			}
+			continue
+		}
+
+		if _, uerr := tx.ExecContext(ctx, `UPDATE outbox SET published_at = NOW() WHERE id = $1;`, row.ID); uerr != nil {
+			return fmt.Errorf("mark outbox published: %w", uerr)
+		}
+	}
+
+	return tx.Commit()
+}
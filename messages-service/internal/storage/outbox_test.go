@@ -0,0 +1,109 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestClaimAndDeliver_Success(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id, topic, key, payload, headers").
+		WithArgs(10).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "topic", "key", "payload", "headers"}).
+			AddRow(int64(1), "input_topic", "mail-1", []byte(`{"a":1}`), []byte(`{"request_id":"req-1"}`)))
+	mock.ExpectExec("UPDATE outbox SET published_at = NOW\\(\\) WHERE id = \\$1").
+		WithArgs(int64(1)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	store := NewOutboxStore(db)
+
+	var delivered []OutboxRow
+	err = store.ClaimAndDeliver(context.Background(), 10, func(_ context.Context, row OutboxRow) error {
+		delivered = append(delivered, row)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ClaimAndDeliver: %v", err)
+	}
+	if len(delivered) != 1 || delivered[0].Topic != "input_topic" || delivered[0].Headers["request_id"] != "req-1" {
+		t.Fatalf("unexpected delivered rows: %+v", delivered)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestClaimAndDeliver_DeliverErrorIncrementsAttempts(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id, topic, key, payload, headers").
+		WithArgs(10).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "topic", "key", "payload", "headers"}).
+			AddRow(int64(2), "output_topic", "mail-2", []byte(`{}`), nil))
+	mock.ExpectExec("UPDATE outbox SET attempts = attempts \\+ 1 WHERE id = \\$1").
+		WithArgs(int64(2)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	store := NewOutboxStore(db)
+
+	deliverErr := errors.New("broker unavailable")
+	err = store.ClaimAndDeliver(context.Background(), 10, func(_ context.Context, row OutboxRow) error {
+		return deliverErr
+	})
+	if err != nil {
+		t.Fatalf("ClaimAndDeliver: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestClaimAndDeliver_NoRowsCommitsEmptyTx(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id, topic, key, payload, headers").
+		WithArgs(10).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "topic", "key", "payload", "headers"}))
+	mock.ExpectCommit()
+
+	store := NewOutboxStore(db)
+
+	called := false
+	err = store.ClaimAndDeliver(context.Background(), 10, func(_ context.Context, row OutboxRow) error {
+		called = true
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ClaimAndDeliver: %v", err)
+	}
+	if called {
+		t.Error("deliver called with no claimed rows")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
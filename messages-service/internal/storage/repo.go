@@ -7,6 +7,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"log/slog"
 )
 
 // Repo реализует интерфейс messages.Repository
@@ -18,14 +19,23 @@ func NewMessagesRepo(db *sql.DB) *Repo {
 	return &Repo{db: db}
 }
 
-func (r *Repo) CreateMail(ctx context.Context, m *messages.Mail) error {
+// CreateMailWithOutbox сохраняет письмо и кладёт события в outbox одной
+// транзакцией, чтобы commit в mails и появление задачи для llm-service не
+// могли разойтись.
+func (r *Repo) CreateMailWithOutbox(ctx context.Context, m *messages.Mail, events []messages.OutboxEvent) error {
 	const query = `
-		INSERT INTO mails 
+		INSERT INTO mails
 			(id, input, from_email, to_email, received_at, attempts, status)
 		VALUES ($1, $2, $3, $4, $5, $6, $7);
 	`
 
-	_, err := r.db.ExecContext(ctx, query,
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := execLogged(ctx, tx, "insert_mail", query,
 		m.ID,
 		m.Input,
 		m.From,
@@ -33,8 +43,15 @@ func (r *Repo) CreateMail(ctx context.Context, m *messages.Mail) error {
 		m.ReceivedAt,
 		m.Attempts,
 		m.Status,
-	)
-	return err
+	); err != nil {
+		return fmt.Errorf("insert mail: %w", err)
+	}
+
+	if err := insertOutboxEvents(ctx, tx, events); err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
 func (r *Repo) GetMail(ctx context.Context, id string) (*messages.Mail, error) {
@@ -93,16 +110,25 @@ func (r *Repo) GetMail(ctx context.Context, id string) (*messages.Mail, error) {
 	return &mail, nil
 }
 
-func (r *Repo) IncrementAttempts(ctx context.Context, id string) error {
+// IncrementAttemptsWithOutbox увеличивает attempts и кладёт события в outbox
+// (переотправку задачи в inputTopic или в retry-топик) одной транзакцией.
+func (r *Repo) IncrementAttemptsWithOutbox(ctx context.Context, id string, events []messages.OutboxEvent) error {
 	const query = `
 		UPDATE mails
 		SET attempts = attempts + 1,
 		    updated_at = NOW()
 		WHERE id = $1;
 	`
-	res, err := r.db.ExecContext(ctx, query, id)
+
+	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
-		return err
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	res, err := execLogged(ctx, tx, "increment_attempts", query, id)
+	if err != nil {
+		return fmt.Errorf("increment attempts: %w", err)
 	}
 
 	rows, err := res.RowsAffected()
@@ -113,10 +139,16 @@ func (r *Repo) IncrementAttempts(ctx context.Context, id string) error {
 		return fmt.Errorf("mail id %s not found", id)
 	}
 
-	return nil
+	if err := insertOutboxEvents(ctx, tx, events); err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
-func (r *Repo) SaveLLMResult(ctx context.Context, id string, classification string, modelAnswer json.RawMessage) error {
+// SaveLLMResultWithOutbox сохраняет результат LLM и кладёт события в outbox
+// (публикацию в outputTopic) одной транзакцией.
+func (r *Repo) SaveLLMResultWithOutbox(ctx context.Context, id string, classification string, modelAnswer json.RawMessage, events []messages.OutboxEvent) error {
 	const query = `
 		UPDATE mails
 		SET classification = $2,
@@ -126,13 +158,19 @@ func (r *Repo) SaveLLMResult(ctx context.Context, id string, classification stri
 		WHERE id = $1;
 	`
 
-	res, err := r.db.ExecContext(ctx, query,
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	res, err := execLogged(ctx, tx, "save_llm_result", query,
 		id,
 		classification,
 		modelAnswer,
 	)
 	if err != nil {
-		return err
+		return fmt.Errorf("update mail: %w", err)
 	}
 
 	rows, err := res.RowsAffected()
@@ -143,10 +181,16 @@ func (r *Repo) SaveLLMResult(ctx context.Context, id string, classification stri
 		return fmt.Errorf("mail id %s not found", id)
 	}
 
-	return nil
+	if err := insertOutboxEvents(ctx, tx, events); err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
-func (r *Repo) MarkAsFailed(ctx context.Context, id string, reason string) error {
+// MarkAsFailedWithOutbox помечает письмо как failed и кладёт события в
+// outbox (публикацию в deadLetterTopic) одной транзакцией.
+func (r *Repo) MarkAsFailedWithOutbox(ctx context.Context, id string, reason string, events []messages.OutboxEvent) error {
 	const query = `
 		UPDATE mails
 		SET status = 'failed',
@@ -155,12 +199,18 @@ func (r *Repo) MarkAsFailed(ctx context.Context, id string, reason string) error
 		WHERE id = $1;
 	`
 
-	res, err := r.db.ExecContext(ctx, query,
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	res, err := execLogged(ctx, tx, "mark_failed", query,
 		id,
 		reason,
 	)
 	if err != nil {
-		return err
+		return fmt.Errorf("mark as failed: %w", err)
 	}
 
 	rows, err := res.RowsAffected()
@@ -171,5 +221,53 @@ func (r *Repo) MarkAsFailed(ctx context.Context, id string, reason string) error
 		return fmt.Errorf("mail id %s not found", id)
 	}
 
+	if err := insertOutboxEvents(ctx, tx, events); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// insertOutboxEvents пишет исходящие события в таблицу outbox в рамках
+// переданной транзакции.
+func insertOutboxEvents(ctx context.Context, tx *sql.Tx, events []messages.OutboxEvent) error {
+	const query = `
+		INSERT INTO outbox (topic, key, payload, headers, created_at)
+		VALUES ($1, $2, $3, $4, NOW());
+	`
+
+	for _, e := range events {
+		var rawHeaders []byte
+		if len(e.Headers) > 0 {
+			var err error
+			rawHeaders, err = json.Marshal(e.Headers)
+			if err != nil {
+				return fmt.Errorf("marshal outbox headers: %w", err)
+			}
+		}
+
+		if _, err := execLogged(ctx, tx, "insert_outbox_event", query, e.Topic, e.Key, []byte(e.Payload), rawHeaders); err != nil {
+			return fmt.Errorf("insert outbox event: %w", err)
+		}
+	}
+
 	return nil
 }
+
+// execLogged выполняет query в рамках tx и логирует результат через
+// логгер из ctx (messages.LoggerFromContext) — его туда кладут
+// messageshttp.Handler и kafka.ValidationConsumer, обогащая
+// request_id/mail_id/attempts, так что каждая строка лога уже несёт
+// полный correlation-контекст без явного протаскивания полей.
+func execLogged(ctx context.Context, tx *sql.Tx, op, query string, args ...any) (sql.Result, error) {
+	res, err := tx.ExecContext(ctx, query, args...)
+
+	log := messages.LoggerFromContext(ctx)
+	if err != nil {
+		log.Error("sql exec failed", slog.String("op", op), slog.Any("error", err))
+		return nil, err
+	}
+
+	log.Debug("sql exec ok", slog.String("op", op))
+	return res, nil
+}
@@ -0,0 +1,79 @@
+// Package health реализует /healthz и /readyz messages-service.
+package health
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// DBPinger — то немногое, что Handler нужно от БД для /readyz.
+// *sql.DB реализует его напрямую.
+type DBPinger interface {
+	PingContext(ctx context.Context) error
+}
+
+// ProducerHealth отражает состояние Kafka-продюсера: отвечал ли брокер на
+// последнюю запись и когда она прошла. Реализуется kafka.Producer.
+type ProducerHealth interface {
+	Healthy() bool
+	LastSuccessAt() time.Time
+}
+
+// Handler отдаёт /healthz (процесс жив) и /readyz (БД отвечает и продюсер
+// не застрял дольше maxProducerStaleness).
+type Handler struct {
+	db                   DBPinger
+	producer             ProducerHealth
+	maxProducerStaleness time.Duration
+	log                  *slog.Logger
+}
+
+// New конструирует Handler.
+// maxProducerStaleness — cfg.Kafka.ReadyMaxStaleness: сколько времени с
+// момента последней успешной отправки в Kafka считается нормой.
+func New(db DBPinger, producer ProducerHealth, maxProducerStaleness time.Duration, log *slog.Logger) *Handler {
+	return &Handler{
+		db:                   db,
+		producer:             producer,
+		maxProducerStaleness: maxProducerStaleness,
+		log:                  log,
+	}
+}
+
+// Register регистрирует /healthz и /readyz.
+func (h *Handler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("/healthz", h.handleHealthz)
+	mux.HandleFunc("/readyz", h.handleReadyz)
+}
+
+// handleHealthz — проверка живости процесса, без обращения к зависимостям.
+func (h *Handler) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+}
+
+// handleReadyz — готовность принимать трафик: БД отвечает на PingContext, а
+// Kafka-продюсер не зафейлил последнюю отправку и не застрял дольше
+// maxProducerStaleness.
+func (h *Handler) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	if err := h.db.PingContext(r.Context()); err != nil {
+		h.log.Warn("readyz: db ping failed", slog.Any("error", err))
+		http.Error(w, "db not ready", http.StatusServiceUnavailable)
+		return
+	}
+
+	if !h.producer.Healthy() {
+		h.log.Warn("readyz: kafka producer unhealthy")
+		http.Error(w, "kafka producer not ready", http.StatusServiceUnavailable)
+		return
+	}
+
+	if staleness := time.Since(h.producer.LastSuccessAt()); staleness > h.maxProducerStaleness {
+		h.log.Warn("readyz: kafka producer stale", slog.Duration("staleness", staleness))
+		http.Error(w, "kafka producer stale", http.StatusServiceUnavailable)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
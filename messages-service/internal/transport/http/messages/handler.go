@@ -1,13 +1,23 @@
 package messageshttp
 
 import (
+	"context"
 	"encoding/json"
 	"log/slog"
 	"net/http"
 
 	"backend/messages-service/internal/messages"
+
+	"github.com/google/uuid"
 )
 
+// requestIDHeader — HTTP-заголовок, из которого Handler берёт входящий
+// correlation ID; если заголовка нет, сгенерированный ID кладётся только в
+// ctx, в ответ этот заголовок не пишется. Это отдельное имя от messages.RequestIDHeader — тот же ID на
+// Kafka-стороне несёт другой заголовок ("request_id"), который
+// kafka.ValidationConsumer кладёт в ctx через messages.ContextWithRequestID.
+const requestIDHeader = "X-Request-ID"
+
 type Handler struct {
 	svc *messages.Service
 	log *slog.Logger
@@ -20,11 +30,34 @@ func New(svc *messages.Service, log *slog.Logger) *Handler {
 	}
 }
 
+// Register регистрирует /process. Этот эндпоинт не зависит от Transport
+// и работает всегда.
 func (h *Handler) Register(mux *http.ServeMux) {
 	mux.HandleFunc("/process", h.handleProcess)
+}
+
+// RegisterValidate регистрирует /validate_processed_message. Вызывается
+// из main.go только когда cfg.Transport равен "http" или "both" — при
+// "kafka" эту роль целиком берёт на себя kafka.ValidationConsumer.
+func (h *Handler) RegisterValidate(mux *http.ServeMux) {
 	mux.HandleFunc("/validate_processed_message", h.handleValidateProcessedMessage)
 }
 
+// requestContext читает X-Request-ID (или генерирует новый correlation ID,
+// если заголовка не было) и кладёт его в ctx вместе с логгером, уже
+// обогащённым этим request_id. Дальше Service, Repository и Kafka Producer
+// достают логгер через messages.LoggerFromContext вместо того, чтобы
+// протаскивать его отдельным параметром.
+func (h *Handler) requestContext(r *http.Request) context.Context {
+	requestID := r.Header.Get(requestIDHeader)
+	if requestID == "" {
+		requestID = uuid.NewString()
+	}
+
+	ctx := messages.ContextWithRequestID(r.Context(), requestID)
+	return messages.ContextWithLogger(ctx, h.log.With(slog.String("request_id", requestID)))
+}
+
 func (h *Handler) handleProcess(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		w.WriteHeader(http.StatusMethodNotAllowed)
@@ -33,17 +66,18 @@ func (h *Handler) handleProcess(w http.ResponseWriter, r *http.Request) {
 
 	defer r.Body.Close()
 
+	ctx := h.requestContext(r)
+	log := messages.LoggerFromContext(ctx)
+
 	var dto messages.IncomingMessageDTO
 	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
-		h.log.Error("failed to decode /process body", slog.Any("error", err))
+		log.Error("failed to decode /process body", slog.Any("error", err))
 		http.Error(w, "invalid json body", http.StatusBadRequest)
 		return
 	}
 
-	if err := h.svc.ProcessIncomingMessage(r.Context(), dto); err != nil {
-		h.log.Error("failed to process incoming message",
-			slog.Any("error", err),
-		)
+	if err := h.svc.ProcessIncomingMessage(ctx, dto); err != nil {
+		log.Error("failed to process incoming message", slog.Any("error", err))
 		http.Error(w, "failed to process message", http.StatusInternalServerError)
 		return
 	}
@@ -60,15 +94,18 @@ func (h *Handler) handleValidateProcessedMessage(w http.ResponseWriter, r *http.
 
 	defer r.Body.Close()
 
+	ctx := h.requestContext(r)
+	log := messages.LoggerFromContext(ctx)
+
 	var dto messages.ValidateMessageDTO
 	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
-		h.log.Error("failed to decode /validate_processed_message body", slog.Any("error", err))
+		log.Error("failed to decode /validate_processed_message body", slog.Any("error", err))
 		http.Error(w, "invalid json body", http.StatusBadRequest)
 		return
 	}
 
-	if err := h.svc.ValidateProcessedMessage(r.Context(), dto); err != nil {
-		h.log.Error("failed to validate processed message",
+	if err := h.svc.ValidateProcessedMessage(ctx, dto); err != nil {
+		log.Error("failed to validate processed message",
 			slog.Any("error", err),
 			slog.String("id", dto.ID),
 		)
@@ -0,0 +1,101 @@
+package schemashttp
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"backend/messages-service/internal/messages/schema"
+)
+
+// Reloader — то немногое, что Handler нужно от schema.Validator.
+type Reloader interface {
+	Reload(sources []schema.Source) error
+	Classifications() []string
+}
+
+// authTokenHeader — заголовок, в котором Handler ждёт cfg.Schemas.AuthToken.
+// /schemas и /schemas/reload не предназначены для внешнего доступа: первый
+// раскрывает список загруженных классификаций, второй перечитывает файлы
+// схем с диска, — поэтому оба закрыты локальным токеном из конфига.
+const authTokenHeader = "X-Auth-Token"
+
+// Handler отдаёт список загруженных JSON Schema и позволяет перечитать их
+// с диска без рестарта сервиса. Оба эндпоинта защищены локальным токеном
+// (authToken), так как доступны только доверенным вызовам внутри периметра.
+type Handler struct {
+	validator Reloader
+	sources   []schema.Source
+	authToken string
+	log       *slog.Logger
+}
+
+// New конструирует Handler.
+// authToken — cfg.Schemas.AuthToken: ожидаемое значение заголовка
+// X-Auth-Token. Пустой authToken отключает проверку (например, в dev).
+func New(validator Reloader, sources []schema.Source, authToken string, log *slog.Logger) *Handler {
+	return &Handler{
+		validator: validator,
+		sources:   sources,
+		authToken: authToken,
+		log:       log,
+	}
+}
+
+// Register регистрирует GET /schemas и POST /schemas/reload.
+func (h *Handler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("/schemas", h.handleList)
+	mux.HandleFunc("/schemas/reload", h.handleReload)
+}
+
+// authorized сверяет X-Auth-Token с h.authToken. Если h.authToken пуст,
+// проверка отключена.
+func (h *Handler) authorized(r *http.Request) bool {
+	if h.authToken == "" {
+		return true
+	}
+	return r.Header.Get(authTokenHeader) == h.authToken
+}
+
+type listResponse struct {
+	Classifications []string `json:"classifications"`
+}
+
+func (h *Handler) handleList(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !h.authorized(r) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	resp := listResponse{Classifications: h.validator.Classifications()}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		h.log.Error("failed to encode /schemas response", slog.Any("error", err))
+	}
+}
+
+func (h *Handler) handleReload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !h.authorized(r) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if err := h.validator.Reload(h.sources); err != nil {
+		h.log.Error("failed to reload schemas", slog.Any("error", err))
+		http.Error(w, "failed to reload schemas", http.StatusInternalServerError)
+		return
+	}
+
+	h.log.Info("schemas reloaded", slog.Any("classifications", h.validator.Classifications()))
+	w.WriteHeader(http.StatusOK)
+}